@@ -3,10 +3,15 @@ package cmd
 import (
 	"bytes"
 	"context"
+	"encoding/csv"
 	"fmt"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 
+	"github.com/golang/protobuf/jsonpb"
 	"github.com/runconduit/conduit/controller/api/util"
 	pb "github.com/runconduit/conduit/controller/gen/public"
 	"github.com/spf13/cobra"
@@ -14,9 +19,17 @@ import (
 
 var namespace string
 var resource = "all"
+var outputFormat string
 
 // var timeWindow string
 
+const (
+	tableOutput = "table"
+	wideOutput  = "wide"
+	jsonOutput  = "json"
+	csvOutput   = "csv"
+)
+
 var statV2Cmd = &cobra.Command{
 	Use:   "statv2 [flags] namespace [NAMESPACE] resource [RESOURCE]",
 	Short: "Display runtime statistics about mesh resources",
@@ -56,6 +69,7 @@ func init() {
 	RootCmd.AddCommand(statV2Cmd)
 	addControlPlaneNetworkingArgs(statV2Cmd)
 	statV2Cmd.PersistentFlags().StringVarP(&timeWindow, "time-window", "t", "1m", "Stat window.  One of: '10s', '1m', '10m', '1h'.")
+	statV2Cmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", tableOutput, "Output format. One of: 'table', 'wide', 'json', 'csv'.")
 }
 
 func requestStatsV2FromApi(client pb.ApiClient) (string, error) {
@@ -70,10 +84,38 @@ func requestStatsV2FromApi(client pb.ApiClient) (string, error) {
 		return "", fmt.Errorf("error calling stat with request: %v", err)
 	}
 
-	return renderStatsV2(resp)
+	output, err := renderStatsV2(resp, outputFormat)
+	if err != nil {
+		return "", err
+	}
+
+	// Surface any Prometheus warnings (e.g. querier limits truncating
+	// results) on stderr. They are written here, before the caller prints
+	// the rendered output, not after it.
+	for _, warning := range resp.Warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+
+	return output, nil
+}
+
+// renderStatsV2 dispatches to the renderer for the requested output format.
+func renderStatsV2(resp *pb.MetricResponseV2, format string) (string, error) {
+	switch format {
+	case tableOutput, "":
+		return renderStatsV2Table(resp)
+	case wideOutput:
+		return renderStatsV2Wide(resp)
+	case jsonOutput:
+		return renderStatsV2JSON(resp)
+	case csvOutput:
+		return renderStatsV2CSV(resp)
+	default:
+		return "", fmt.Errorf("unsupported output format %q, must be one of: table, wide, json, csv", format)
+	}
 }
 
-func renderStatsV2(resp *pb.MetricResponseV2) (string, error) {
+func renderStatsV2Table(resp *pb.MetricResponseV2) (string, error) {
 	var buffer bytes.Buffer
 	w := tabwriter.NewWriter(&buffer, 0, 0, padding, ' ', tabwriter.AlignRight)
 	writeStatsV2ToBuffer(resp, w)
@@ -86,6 +128,15 @@ func renderStatsV2(resp *pb.MetricResponseV2) (string, error) {
 	return out, nil
 }
 
+// latestDatapoint returns a metric's most recent datapoint. QueryProm issues
+// a range query covering [now-window, now] whenever Window is set (which
+// buildMetricRequestV2 always sets), and convertSampleStream preserves
+// Prometheus's chronological (oldest-first) ordering, so the current value
+// is the last datapoint, not the first.
+func latestDatapoint(metric *pb.MetricSeriesV2) *pb.MetricDatapoint {
+	return metric.Datapoints[len(metric.Datapoints)-1]
+}
+
 func writeStatsV2ToBuffer(resp *pb.MetricResponseV2, w *tabwriter.Writer) {
 	nameHeader := "NAME"
 	maxNameLength := len(nameHeader)
@@ -96,7 +147,7 @@ func writeStatsV2ToBuffer(resp *pb.MetricResponseV2, w *tabwriter.Writer) {
 			continue
 		}
 
-		name := metric.Metadata["k8s_deployment"]
+		name := resourceNameFromMetadata(metric.Metadata)
 
 		if len(name) > maxNameLength {
 			maxNameLength = len(name)
@@ -106,13 +157,14 @@ func writeStatsV2ToBuffer(resp *pb.MetricResponseV2, w *tabwriter.Writer) {
 			stats[name] = &row{}
 		}
 
+		datapoint := latestDatapoint(metric)
 		switch metric.Name {
 		case pb.MetricName_REQUEST_RATE:
-			stats[name].requestRate = metric.Datapoints[0].Value.GetGauge()
+			stats[name].requestRate = datapoint.Value.GetGauge()
 		case pb.MetricName_SUCCESS_RATE:
-			stats[name].successRate = metric.Datapoints[0].Value.GetGauge()
+			stats[name].successRate = datapoint.Value.GetGauge()
 		case pb.MetricName_LATENCY:
-			for _, v := range metric.Datapoints[0].Value.GetHistogram().Values {
+			for _, v := range datapoint.Value.GetHistogram().Values {
 				switch v.Label {
 				case pb.HistogramLabel_P50:
 					stats[name].latencyP50 = v.Value
@@ -145,6 +197,216 @@ func writeStatsV2ToBuffer(resp *pb.MetricResponseV2, w *tabwriter.Writer) {
 	}
 }
 
+// statRowV2 is the aggregated view of a MetricRequestV2 response used by the
+// wide and csv output formats, which (unlike the table format) surface
+// per-resource identity and any extra Prometheus labels.
+type statRowV2 struct {
+	namespace   string
+	kind        string
+	name        string
+	requestRate float64
+	successRate float64
+	latencyP50  int64
+	latencyP99  int64
+	timestampMs int64
+	extraLabels string
+}
+
+// resourceKindLabels maps the label conduit groups Prometheus queries by to
+// the resource kind it identifies. REQUEST_RATE is grouped by one of the
+// k8s_*/namespace labels per the requested --resource kind, while
+// SUCCESS_RATE/LATENCY/RESPONSE_CLASS are always grouped by
+// target_deployment (see resourceQuery.Resource.PromLabel's doc comment) -
+// both map to the "deployment" kind so a deployment's rows merge into one.
+var resourceKindLabels = map[string]string{
+	"k8s_deployment":             "deployment",
+	"target_deployment":          "deployment",
+	"k8s_pod_template_hash":      "pod",
+	"k8s_replica_set":            "replicaset",
+	"k8s_daemon_set":             "daemonset",
+	"k8s_job":                    "job",
+	"k8s_replication_controller": "replicationcontroller",
+	"namespace":                  "namespace",
+}
+
+// resourceNameFromMetadata extracts the resource name a metric's Prometheus
+// labels identify, checking every label resourceKindLabels recognizes.
+func resourceNameFromMetadata(metadata map[string]string) string {
+	for label := range resourceKindLabels {
+		if v, ok := metadata[label]; ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// resourceKindFromMetadata extracts the resource kind a metric's Prometheus
+// labels identify, checking every label resourceKindLabels recognizes.
+func resourceKindFromMetadata(metadata map[string]string) string {
+	for label, kind := range resourceKindLabels {
+		if _, ok := metadata[label]; ok {
+			return kind
+		}
+	}
+	return ""
+}
+
+func aggregateStatsV2(resp *pb.MetricResponseV2, namespace string) []*statRowV2 {
+	rows := make(map[string]*statRowV2)
+
+	for _, metric := range resp.Metrics {
+		if len(metric.Datapoints) == 0 {
+			continue
+		}
+
+		kind := resourceKindFromMetadata(metric.Metadata)
+		name := resourceNameFromMetadata(metric.Metadata)
+
+		key := kind + "/" + name
+		r, ok := rows[key]
+		if !ok {
+			r = &statRowV2{
+				namespace:   namespace,
+				kind:        kind,
+				name:        name,
+				extraLabels: extraMetadataLabels(metric.Metadata),
+			}
+			rows[key] = r
+		}
+
+		datapoint := latestDatapoint(metric)
+		r.timestampMs = datapoint.TimestampMs
+
+		switch metric.Name {
+		case pb.MetricName_REQUEST_RATE:
+			r.requestRate = datapoint.Value.GetGauge()
+		case pb.MetricName_SUCCESS_RATE:
+			r.successRate = datapoint.Value.GetGauge()
+		case pb.MetricName_LATENCY:
+			for _, v := range datapoint.Value.GetHistogram().Values {
+				switch v.Label {
+				case pb.HistogramLabel_P50:
+					r.latencyP50 = v.Value
+				case pb.HistogramLabel_P99:
+					r.latencyP99 = v.Value
+				}
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(rows))
+	for key := range rows {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make([]*statRowV2, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, rows[key])
+	}
+	return result
+}
+
+// extraMetadataLabels renders any Prometheus labels beyond the ones already
+// surfaced as dedicated columns, as a sorted "key=value,..." string.
+func extraMetadataLabels(metadata map[string]string) string {
+	keys := make([]string, 0, len(metadata))
+	for k := range metadata {
+		if _, isResourceLabel := resourceKindLabels[k]; isResourceLabel {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", k, metadata[k]))
+	}
+	return strings.Join(pairs, ",")
+}
+
+func renderStatsV2Wide(resp *pb.MetricResponseV2) (string, error) {
+	var buffer bytes.Buffer
+	w := tabwriter.NewWriter(&buffer, 0, 0, padding, ' ', tabwriter.AlignLeft)
+
+	fmt.Fprintln(w, strings.Join([]string{
+		"NAMESPACE",
+		"KIND",
+		"NAME",
+		"REQUEST_RATE",
+		"SUCCESS_RATE",
+		"P50_LATENCY",
+		"P99_LATENCY",
+		"TIMESTAMP_MS",
+		"LABELS\t", // trailing \t is required to format last column
+	}, "\t"))
+
+	for _, r := range aggregateStatsV2(resp, namespace) {
+		fmt.Fprintf(
+			w,
+			"%s\t%s\t%s\t%.1frps\t%.2f%%\t%dms\t%dms\t%d\t%s\t\n",
+			r.namespace,
+			r.kind,
+			r.name,
+			r.requestRate,
+			r.successRate*100,
+			r.latencyP50,
+			r.latencyP99,
+			r.timestampMs,
+			r.extraLabels,
+		)
+	}
+
+	w.Flush()
+	return buffer.String(), nil
+}
+
+func renderStatsV2JSON(resp *pb.MetricResponseV2) (string, error) {
+	marshaler := jsonpb.Marshaler{EmitDefaults: true}
+	out, err := marshaler.MarshalToString(resp)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling stat response to json: %v", err)
+	}
+	return out + "\n", nil
+}
+
+func renderStatsV2CSV(resp *pb.MetricResponseV2) (string, error) {
+	var buffer bytes.Buffer
+	w := csv.NewWriter(&buffer)
+
+	err := w.Write([]string{
+		"namespace", "kind", "name", "request_rate", "success_rate", "p50_latency_ms", "p99_latency_ms", "timestamp_ms", "labels",
+	})
+	if err != nil {
+		return "", err
+	}
+
+	for _, r := range aggregateStatsV2(resp, namespace) {
+		err := w.Write([]string{
+			r.namespace,
+			r.kind,
+			r.name,
+			strconv.FormatFloat(r.requestRate, 'f', 1, 64),
+			strconv.FormatFloat(r.successRate*100, 'f', 2, 64),
+			strconv.FormatInt(r.latencyP50, 10),
+			strconv.FormatInt(r.latencyP99, 10),
+			strconv.FormatInt(r.timestampMs, 10),
+			r.extraLabels,
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", err
+	}
+
+	return buffer.String(), nil
+}
+
 func buildMetricRequestV2() (*pb.MetricRequestV2, error) {
 	window, err := util.GetWindow(timeWindow)
 	if err != nil {
@@ -154,6 +416,8 @@ func buildMetricRequestV2() (*pb.MetricRequestV2, error) {
 	return &pb.MetricRequestV2{
 		Metrics: []pb.MetricName{
 			pb.MetricName_REQUEST_RATE,
+			pb.MetricName_SUCCESS_RATE,
+			pb.MetricName_LATENCY,
 		},
 		Window:    window,
 		Resource:  resource,
@@ -2,88 +2,84 @@ package public
 
 import (
 	"context"
-	"fmt"
+	"sync"
 
+	"github.com/runconduit/conduit/pkg/resourceQuery"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
 	apiv1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-var resourceTypes = []string{
-	"deployments",
-	"replicaSets",
-	"services",
-	"pods",
-}
-
-func (h *handler) getAllResourceMetrics(context context.Context, namespace string) (map[string]map[string]labelledSample, error) {
-	depResult, err := h.getDeploymentMetrics(context, namespace)
+// getAllResourceMetrics fans getResourceMetrics out across resource kinds,
+// bounded to maxConcurrentResourceQueries goroutines at a time, mirroring
+// grpcServer.queryResources' fan-out in helper3.go.
+func (h *handler) getAllResourceMetrics(ctx context.Context, namespace string) (map[string]map[string]labelledSample, []string, error) {
+	resources, err := resourceQuery.ByName(resourceQuery.AllResources)
 	if err != nil {
-		return nil, err
-	}
-	podResult, err := h.getPodMetrics(context, namespace)
-	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	result := map[string]map[string]labelledSample{
-		"deployments": depResult,
-		"pods":        podResult,
-	}
-	return result, nil
-}
+	var (
+		mu       sync.Mutex
+		result   = make(map[string]map[string]labelledSample, len(resources))
+		warnings = make([]string, 0)
+	)
 
-func (h *handler) getDeploymentMetrics(context context.Context, namespace string) (map[string]labelledSample, error) {
-	if namespace == "" {
-		namespace = apiv1.NamespaceDefault
-	}
-	metricsResult := make(map[string]labelledSample)
+	g, gctx := errgroup.WithContext(ctx)
+	sem := make(chan struct{}, maxConcurrentResourceQueries)
 
-	log.Printf("Listing deployments in namespace %q:\n", namespace)
-	deploymentsClient := h.k8sClient.AppsV1beta1().Deployments(namespace)
-	list, err := deploymentsClient.List(metav1.ListOptions{})
-	if err != nil {
-		return nil, err
-	}
-	for _, item := range list.Items {
-		fmt.Println(item.Name, item.Namespace, item.Labels)
-		metricsResult[item.Name] = labelledSample{}
-	}
+	for _, resource := range resources {
+		resource := resource
+		g.Go(func() error {
+			sem <- struct{}{}
+			defer func() { <-sem }()
 
-	queryResult := h.deployRequestRate(context, namespace)
-	if err != nil {
-		return nil, err
+			metrics, w, err := h.getResourceMetrics(gctx, namespace, resource)
+			if err != nil {
+				return err
+			}
+
+			mu.Lock()
+			result[resource.Name] = metrics
+			warnings = append(warnings, w...)
+			mu.Unlock()
+			return nil
+		})
 	}
 
-	for _, m := range queryResult.res {
-		metricsResult[m.Labels["k8s_deployment"]] = *m
+	if err := g.Wait(); err != nil {
+		log.Errorf("getAllResourceMetrics failed with: %s", err)
+		return nil, nil, err
 	}
-	return metricsResult, nil
+
+	return result, warnings, nil
 }
 
-func (h *handler) getPodMetrics(context context.Context, namespace string) (map[string]labelledSample, error) {
+// getResourceMetrics lists every resource of kind in namespace to seed
+// zero-valued rows for resources with no observed traffic, then merges in
+// whatever request-rate data Prometheus has for them.
+func (h *handler) getResourceMetrics(ctx context.Context, namespace string, resource resourceQuery.Resource) (map[string]labelledSample, []string, error) {
 	if namespace == "" {
 		namespace = apiv1.NamespaceDefault
 	}
 	metricsResult := make(map[string]labelledSample)
 
-	log.Printf("Listing pods in namespace %q:\n", namespace)
-	podList, err := h.k8sClient.CoreV1().Pods(namespace).List(metav1.ListOptions{})
+	log.Printf("Listing %s in namespace %q:\n", resource.Name, namespace)
+	names, err := resource.K8sListFn(h.k8sClient, namespace)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	for _, item := range podList.Items {
-		fmt.Println(item.Name, item.Namespace, item.OwnerReferences, item.Labels["pod-template-hash"])
-		metricsResult[item.Name] = labelledSample{}
+	for _, name := range names {
+		metricsResult[name] = labelledSample{}
 	}
 
-	queryResult := h.podRequestRate(context, namespace)
-	if err != nil {
-		return nil, err
+	queryResult := h.requestRateFor(ctx, namespace, resource, nil)
+	if queryResult.err != nil {
+		return nil, nil, queryResult.err
 	}
 
 	for _, m := range queryResult.res {
-		metricsResult[m.Labels["k8s_pod_template_hash"]] = *m
+		metricsResult[m.Labels[resource.PromLabel]] = *m
 	}
-	return metricsResult, nil
+	return metricsResult, queryResult.warnings, nil
 }
@@ -2,19 +2,55 @@ package public
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/duration"
+	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/common/model"
 	pb "github.com/runconduit/conduit/controller/gen/public"
+	"github.com/runconduit/conduit/pkg/resourceQuery"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// defaultRateWindow is the irate() window used when the request doesn't
+	// specify one, matching the window reqTotalQuery has always used.
+	defaultRateWindow = "10m"
+
+	// successRateQueryFmt and latencyQueryFmt query responses_total and
+	// response_latency_ms_bucket. telemetry.go labels those metrics only
+	// with source_deployment, target_deployment, http_status_code, and
+	// classification — there is no namespace label and no per-resource-kind
+	// (k8s_deployment/k8s_pod_template_hash/...) label on them, so unlike
+	// reqTotalQuery these can't be scoped by namespace or grouped by
+	// resourceQuery.Resource.PromLabel. They always group by
+	// target_deployment, the only resource dimension these metrics carry.
+	successRateQueryFmt = "sum(irate(responses_total{classification=\"success\"}[%s])) by (target_deployment) / sum(irate(responses_total[%s])) by (target_deployment)"
+	latencyQueryFmt     = "histogram_quantile(%s, sum(irate(response_latency_ms_bucket[%s])) by (le, target_deployment))"
+
+	// responseClassQueryFmt groups response rate by classification
+	// (success/failure) and by status_bucket, the first digit of
+	// http_status_code (2xx/4xx/5xx). Same label caveat as above: grouped by
+	// target_deployment, since responses_total carries no other resource
+	// dimension.
+	responseClassQueryFmt = "sum(label_replace(irate(responses_total[%s]), \"status_bucket\", \"${1}xx\", \"http_status_code\", \"(.).*\")) by (classification, status_bucket, target_deployment)"
+
+	// maxConcurrentResourceQueries bounds how many resource kinds' Prometheus
+	// queries run at once for a "all" resources request.
+	maxConcurrentResourceQueries = 4
 )
 
 func (s *grpcServer) StatV2(ctx context.Context, req *pb.MetricRequestV2) (*pb.MetricResponseV2, error) {
 	var err error
 	resultsCh := make(chan metricResultV2)
 	metrics := make([]*pb.MetricSeriesV2, 0)
+	warnings := make([]string, 0)
 
 	// kick off requests
 	for _, metric := range req.Metrics {
@@ -31,11 +67,12 @@ func (s *grpcServer) StatV2(ctx context.Context, req *pb.MetricRequestV2) (*pb.M
 			for i := range result.series {
 				metrics = append(metrics, &result.series[i])
 			}
+			warnings = append(warnings, result.warnings...)
 		}
 	}
 
 	// if an error occurred, return the error, along with partial results
-	return &pb.MetricResponseV2{Metrics: metrics}, err
+	return &pb.MetricResponseV2{Metrics: metrics, Warnings: warnings}, err
 }
 
 func (s *grpcServer) queryMetric2(ctx context.Context, req *pb.MetricRequestV2, metric pb.MetricName) metricResultV2 {
@@ -43,7 +80,13 @@ func (s *grpcServer) queryMetric2(ctx context.Context, req *pb.MetricRequestV2,
 
 	switch metric {
 	case pb.MetricName_REQUEST_RATE:
-		result.series, result.err = s.requestRate2(ctx, req)
+		result.series, result.warnings, result.err = s.requestRate2(ctx, req)
+	case pb.MetricName_SUCCESS_RATE:
+		result.series, result.warnings, result.err = s.successRate2(ctx, req)
+	case pb.MetricName_LATENCY:
+		result.series, result.warnings, result.err = s.latency2(ctx, req)
+	case pb.MetricName_RESPONSE_CLASS:
+		result.series, result.warnings, result.err = s.responseClass2(ctx, req)
 	default:
 		result.series = nil
 		result.err = fmt.Errorf("unsupported metric: %s", metric)
@@ -53,115 +96,346 @@ func (s *grpcServer) queryMetric2(ctx context.Context, req *pb.MetricRequestV2,
 	return result
 }
 
-type metricFn func(context.Context, string) promResult
+// queryResources fans queryFn out across resources, bounded to
+// maxConcurrentResourceQueries goroutines at a time, and merges the
+// results. A single resource (the common case of `--resource deployments`)
+// just runs queryFn once.
+func (s *grpcServer) queryResources(
+	ctx context.Context,
+	namespace string,
+	resources []resourceQuery.Resource,
+	window *duration.Duration,
+	queryFn func(context.Context, string, resourceQuery.Resource, *duration.Duration) promResult,
+) promResult {
+	var (
+		mu       sync.Mutex
+		metrics  = make([]*labelledSample, 0)
+		warnings = make([]string, 0)
+	)
 
-func (s *grpcServer) allResourcesRequestRate(ctx context.Context, namespace string) promResult {
-	var err error
-	resources := []metricFn{s.deployRequestRate, s.podRequestRate} // todo add all resources
-	resultsCh := make(chan promResult)
-	metrics := make([]*labelledSample, 0)
+	g, gctx := errgroup.WithContext(ctx)
+	sem := make(chan struct{}, maxConcurrentResourceQueries)
 
-	// kick off requests
-	for _, getFn := range resources {
-		go func() { resultsCh <- getFn(ctx, namespace) }()
+	for _, resource := range resources {
+		resource := resource
+		g.Go(func() error {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			result := queryFn(gctx, namespace, resource, window)
+			if result.err != nil {
+				return result.err
+			}
+
+			mu.Lock()
+			metrics = append(metrics, result.res...)
+			warnings = append(warnings, result.warnings...)
+			mu.Unlock()
+			return nil
+		})
 	}
-	// process results
-	for _ = range resources {
-		metricsResult := <-resultsCh
-		if metricsResult.err != nil {
-			log.Errorf("Stat -> queryMetric2 -> allResourcesRequestRate failed with: %s", metricsResult.err)
-			err = metricsResult.err
-		} else {
-			metrics = append(metrics, metricsResult.res...)
-		}
+
+	if err := g.Wait(); err != nil {
+		log.Errorf("queryResources failed with: %s", err)
+		return promResult{err: err}
 	}
 
-	return promResult{res: metrics, err: err}
+	return promResult{res: metrics, warnings: warnings}
 }
 
-func (s *grpcServer) requestRate2(ctx context.Context, req *pb.MetricRequestV2) ([]pb.MetricSeriesV2, error) {
-	var result promResult
-
-	switch req.Resource {
-	case "all":
-		result = s.allResourcesRequestRate(ctx, req.Namespace)
-	case "deployments":
-		result = s.deployRequestRate(ctx, req.Namespace)
-	case "pods":
-		result = s.podRequestRate(ctx, req.Namespace)
-	default:
-		result.err = errors.New("Invalid resource specified")
+func (s *grpcServer) requestRate2(ctx context.Context, req *pb.MetricRequestV2) ([]pb.MetricSeriesV2, []string, error) {
+	resources, err := resourceQuery.ByName(req.Resource)
+	if err != nil {
+		return nil, nil, err
 	}
 
+	result := s.queryResources(ctx, req.Namespace, resources, req.Window, s.requestRateFor)
 	if result.err != nil {
-		return nil, result.err
+		return nil, nil, result.err
 	}
 
-	return processRequestRateV2(result.res), nil
+	return processRequestRateV2(result.res), result.warnings, nil
 }
 
-func (s *grpcServer) podRequestRate(ctx context.Context, namespace string) promResult {
-	query := fmt.Sprintf(reqTotalQuery, namespace, "k8s_pod_template_hash") // k8s_pod_template_hash is grouped pods; use pod name when available
-	return s.queryProm(ctx, query)
+// requestRateFor issues the request-rate query for a single resource kind.
+func (s *grpcServer) requestRateFor(ctx context.Context, namespace string, resource resourceQuery.Resource, window *duration.Duration) promResult {
+	query := fmt.Sprintf(reqTotalQuery, namespace, resource.PromLabel)
+	return s.queryProm(ctx, query, window)
 }
 
-func (s *grpcServer) deployRequestRate(ctx context.Context, namespace string) promResult {
-	query := fmt.Sprintf(reqTotalQuery, namespace, "k8s_deployment")
-	return s.queryProm(ctx, query)
-}
-
-func (s *grpcServer) queryProm(ctx context.Context, query string) promResult {
+func (s *grpcServer) queryProm(ctx context.Context, query string, window *duration.Duration) promResult {
 	result := promResult{}
-	queryRsp, err := s.QueryProm(ctx, query)
+	queryRsp, warnings, err := s.QueryProm(ctx, query, window)
 	if err != nil {
 		result.err = err
 		return result
 	}
 	result.res = queryRsp
+	result.warnings = warnings
 	return result
 }
 
-func (s *grpcServer) QueryProm(ctx context.Context, query string) ([]*labelledSample, error) {
-	log.Debugf("Query request: %+v", query)
+// QueryProm issues an instantaneous query when window is nil, or a ranged
+// query covering [now-window, now] when one is provided. Any warnings
+// Prometheus returns alongside a successful response (e.g. about querier
+// limits truncating results) are passed through rather than discarded.
+func (s *grpcServer) QueryProm(ctx context.Context, query string, window *duration.Duration) ([]*labelledSample, []string, error) {
+	log.Debugf("Query request: %+v, window: %+v", query, window)
+
+	if window == nil {
+		return s.queryPromInstant(ctx, query)
+	}
+	return s.queryPromRange(ctx, query, window)
+}
+
+func (s *grpcServer) queryPromInstant(ctx context.Context, query string) ([]*labelledSample, []string, error) {
 	end := time.Now()
-	samples := make([]*labelledSample, 0)
 
-	// single data point (aka summary) query
-	res, err := s.prometheusAPI.Query(ctx, query, end)
+	res, warnings, err := s.prometheusAPI.Query(ctx, query, end)
 	if err != nil {
 		log.Errorf("Query(%+v, %+v) failed with: %+v", query, end, err)
-		return nil, err
+		return nil, nil, err
 	}
-	log.Debugf("Query response: %+v", res)
+	log.Debugf("Query response: %+v, warnings: %+v", res, warnings)
 
 	if res.Type() != model.ValVector {
 		err = fmt.Errorf("Unexpected query result type (expected Vector): %s", res.Type())
 		log.Error(err)
-		return nil, err
+		return nil, nil, err
 	}
 
+	samples := make([]*labelledSample, 0)
 	for _, s := range res.(model.Vector) {
 		samples = append(samples, convertSample(s))
 	}
 
-	return samples, nil
+	return samples, []string(warnings), nil
+}
+
+func (s *grpcServer) queryPromRange(ctx context.Context, query string, window *duration.Duration) ([]*labelledSample, []string, error) {
+	w, err := ptypes.Duration(window)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	end := time.Now()
+	r := promv1.Range{
+		Start: end.Add(-w),
+		End:   end,
+		Step:  queryRangeStep(w),
+	}
+
+	res, warnings, err := s.prometheusAPI.QueryRange(ctx, query, r)
+	if err != nil {
+		log.Errorf("QueryRange(%+v, %+v) failed with: %+v", query, r, err)
+		return nil, nil, err
+	}
+	log.Debugf("QueryRange response: %+v, warnings: %+v", res, warnings)
+
+	if res.Type() != model.ValMatrix {
+		err = fmt.Errorf("Unexpected query result type (expected Matrix): %s", res.Type())
+		log.Error(err)
+		return nil, nil, err
+	}
+
+	samples := make([]*labelledSample, 0)
+	for _, s := range res.(model.Matrix) {
+		samples = append(samples, convertSampleStream(s))
+	}
+
+	return samples, []string(warnings), nil
 }
 
 func processRequestRateV2(samples []*labelledSample) []pb.MetricSeriesV2 {
+	return processGaugeV2(pb.MetricName_REQUEST_RATE, samples)
+}
+
+// successRate2 reports the success rate grouped by target_deployment, the
+// only resource dimension responses_total carries — so unlike requestRate2
+// it can't fan out per resourceQuery.Resource, but still validates
+// req.Resource so an unrecognized --resource still errors.
+func (s *grpcServer) successRate2(ctx context.Context, req *pb.MetricRequestV2) ([]pb.MetricSeriesV2, []string, error) {
+	if _, err := resourceQuery.ByName(req.Resource); err != nil {
+		return nil, nil, err
+	}
+
+	result := s.successRateFor(ctx, req.Window)
+	if result.err != nil {
+		return nil, nil, result.err
+	}
+
+	return processGaugeV2(pb.MetricName_SUCCESS_RATE, result.res), result.warnings, nil
+}
+
+// successRateFor issues the success-rate query.
+func (s *grpcServer) successRateFor(ctx context.Context, window *duration.Duration) promResult {
+	w, err := promRateWindow(window)
+	if err != nil {
+		return promResult{err: err}
+	}
+
+	query := fmt.Sprintf(successRateQueryFmt, w, w)
+	return s.queryProm(ctx, query, window)
+}
+
+// responseClass2 reports the classification/status-bucket breakdown grouped
+// by target_deployment, the only resource dimension responses_total
+// carries — so unlike requestRate2 it can't fan out per resourceQuery.Resource,
+// but still validates req.Resource so an unrecognized --resource still errors.
+func (s *grpcServer) responseClass2(ctx context.Context, req *pb.MetricRequestV2) ([]pb.MetricSeriesV2, []string, error) {
+	if _, err := resourceQuery.ByName(req.Resource); err != nil {
+		return nil, nil, err
+	}
+
+	result := s.responseClassFor(ctx, req.Window)
+	if result.err != nil {
+		return nil, nil, result.err
+	}
+
+	return processGaugeV2(pb.MetricName_RESPONSE_CLASS, result.res), result.warnings, nil
+}
+
+// responseClassFor issues the classification/status-bucket breakdown query,
+// so callers can see failure ratios per deployment without re-deriving them
+// from raw request-rate/success-rate series.
+func (s *grpcServer) responseClassFor(ctx context.Context, window *duration.Duration) promResult {
+	w, err := promRateWindow(window)
+	if err != nil {
+		return promResult{err: err}
+	}
+
+	query := fmt.Sprintf(responseClassQueryFmt, w)
+	return s.queryProm(ctx, query, window)
+}
+
+// latency2 reports P50/P99 latency grouped by target_deployment, the only
+// resource dimension response_latency_ms_bucket carries — so unlike
+// requestRate2 it can't fan out per resourceQuery.Resource, but still
+// validates req.Resource so an unrecognized --resource still errors.
+func (s *grpcServer) latency2(ctx context.Context, req *pb.MetricRequestV2) ([]pb.MetricSeriesV2, []string, error) {
+	if _, err := resourceQuery.ByName(req.Resource); err != nil {
+		return nil, nil, err
+	}
+
+	return s.latencyFor(ctx, req.Window)
+}
+
+// latencyFor issues the P50 and P99 histogram_quantile queries and merges
+// them into one HistogramLabel_P50/P99 series per label set.
+func (s *grpcServer) latencyFor(ctx context.Context, window *duration.Duration) ([]pb.MetricSeriesV2, []string, error) {
+	w, err := promRateWindow(window)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p50Query := fmt.Sprintf(latencyQueryFmt, "0.5", w)
+	p99Query := fmt.Sprintf(latencyQueryFmt, "0.99", w)
+
+	p50Result := s.queryProm(ctx, p50Query, window)
+	if p50Result.err != nil {
+		return nil, nil, p50Result.err
+	}
+
+	p99Result := s.queryProm(ctx, p99Query, window)
+	if p99Result.err != nil {
+		return nil, nil, p99Result.err
+	}
+
+	warnings := append(p50Result.warnings, p99Result.warnings...)
+	return processLatencyV2(p50Result.res, p99Result.res), warnings, nil
+}
+
+// promRateWindow renders window as a Prometheus duration string suitable for
+// use inside an irate() selector, falling back to defaultRateWindow when no
+// window was requested.
+func promRateWindow(window *duration.Duration) (string, error) {
+	if window == nil {
+		return defaultRateWindow, nil
+	}
+
+	w, err := ptypes.Duration(window)
+	if err != nil {
+		return "", err
+	}
+
+	return model.Duration(w).String(), nil
+}
+
+func processGaugeV2(name pb.MetricName, samples []*labelledSample) []pb.MetricSeriesV2 {
 	result := make([]pb.MetricSeriesV2, 0)
 
 	for _, s := range samples {
-		datapoint := pb.MetricDatapoint{
-			Value:       &pb.MetricValue{Value: &pb.MetricValue_Gauge{Gauge: s.Value.Value}},
-			TimestampMs: s.Value.TimestampMs,
+		datapoints := make([]*pb.MetricDatapoint, 0, len(s.Values))
+		for _, v := range s.Values {
+			datapoints = append(datapoints, &pb.MetricDatapoint{
+				Value:       &pb.MetricValue{Value: &pb.MetricValue_Gauge{Gauge: v.Value}},
+				TimestampMs: v.TimestampMs,
+			})
 		}
-		series := pb.MetricSeriesV2{
-			Name:       pb.MetricName_REQUEST_RATE,
-			Datapoints: []*pb.MetricDatapoint{&datapoint},
+
+		result = append(result, pb.MetricSeriesV2{
+			Name:       name,
+			Datapoints: datapoints,
 			Metadata:   s.Labels,
+		})
+	}
+
+	return result
+}
+
+func processLatencyV2(p50, p99 []*labelledSample) []pb.MetricSeriesV2 {
+	p99ByLabels := make(map[string]*labelledSample, len(p99))
+	for _, s := range p99 {
+		p99ByLabels[labelsKey(s.Labels)] = s
+	}
+
+	result := make([]pb.MetricSeriesV2, 0, len(p50))
+	for _, p50Sample := range p50 {
+		p99Sample := p99ByLabels[labelsKey(p50Sample.Labels)]
+
+		datapoints := make([]*pb.MetricDatapoint, 0, len(p50Sample.Values))
+		for i, v := range p50Sample.Values {
+			values := []*pb.HistogramValue_Value{
+				{Label: pb.HistogramLabel_P50, Value: int64(v.Value)},
+			}
+			if p99Sample != nil && i < len(p99Sample.Values) {
+				values = append(values, &pb.HistogramValue_Value{
+					Label: pb.HistogramLabel_P99,
+					Value: int64(p99Sample.Values[i].Value),
+				})
+			}
+
+			datapoints = append(datapoints, &pb.MetricDatapoint{
+				Value:       &pb.MetricValue{Value: &pb.MetricValue_Histogram{Histogram: &pb.HistogramValue{Values: values}}},
+				TimestampMs: v.TimestampMs,
+			})
 		}
-		result = append(result, series)
+
+		result = append(result, pb.MetricSeriesV2{
+			Name:       pb.MetricName_LATENCY,
+			Datapoints: datapoints,
+			Metadata:   p50Sample.Labels,
+		})
 	}
 
 	return result
 }
+
+// labelsKey renders a label set into a stable, comparable string so P50 and
+// P99 samples for the same series can be matched up.
+func labelsKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(labels[k])
+		b.WriteByte(',')
+	}
+	return b.String()
+}
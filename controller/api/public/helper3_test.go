@@ -0,0 +1,125 @@
+package public
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/common/model"
+	pb "github.com/runconduit/conduit/controller/gen/public"
+)
+
+func TestSuccessRate2(t *testing.T) {
+	mock := &mockPromAPI{
+		queryResult: model.Vector{
+			&model.Sample{
+				Metric:    model.Metric{"target_deployment": "web"},
+				Value:     0.9,
+				Timestamp: 1000,
+			},
+		},
+	}
+	s := &grpcServer{prometheusAPI: mock}
+
+	series, _, err := s.successRate2(context.Background(), &pb.MetricRequestV2{Resource: "deployments"})
+	if err != nil {
+		t.Fatalf("successRate2 returned unexpected error: %v", err)
+	}
+	if len(series) != 1 {
+		t.Fatalf("expected 1 series, got %d", len(series))
+	}
+	if series[0].Metadata["target_deployment"] != "web" {
+		t.Errorf("unexpected metadata: %+v", series[0].Metadata)
+	}
+
+	if _, _, err := s.successRate2(context.Background(), &pb.MetricRequestV2{Resource: "widgets"}); err == nil {
+		t.Error("expected an error for an unrecognized resource, got nil")
+	}
+}
+
+func TestLatency2(t *testing.T) {
+	mock := &mockPromAPI{
+		queryResult: model.Vector{
+			&model.Sample{
+				Metric:    model.Metric{"target_deployment": "web"},
+				Value:     42,
+				Timestamp: 1000,
+			},
+		},
+	}
+	s := &grpcServer{prometheusAPI: mock}
+
+	series, _, err := s.latency2(context.Background(), &pb.MetricRequestV2{Resource: "pods"})
+	if err != nil {
+		t.Fatalf("latency2 returned unexpected error: %v", err)
+	}
+	if len(series) != 1 {
+		t.Fatalf("expected 1 series, got %d", len(series))
+	}
+	if len(series[0].Datapoints) != 1 {
+		t.Fatalf("expected 1 datapoint, got %d", len(series[0].Datapoints))
+	}
+
+	if _, _, err := s.latency2(context.Background(), &pb.MetricRequestV2{Resource: "widgets"}); err == nil {
+		t.Error("expected an error for an unrecognized resource, got nil")
+	}
+}
+
+func TestProcessLatencyV2(t *testing.T) {
+	p50 := []*labelledSample{
+		{Labels: map[string]string{"target_deployment": "web"}, Values: []sampleVal{{Value: 10, TimestampMs: 1000}}},
+	}
+	p99 := []*labelledSample{
+		{Labels: map[string]string{"target_deployment": "web"}, Values: []sampleVal{{Value: 50, TimestampMs: 1000}}},
+	}
+
+	series := processLatencyV2(p50, p99)
+	if len(series) != 1 {
+		t.Fatalf("expected 1 series, got %d", len(series))
+	}
+	if len(series[0].Datapoints) != 1 {
+		t.Fatalf("expected 1 datapoint, got %d", len(series[0].Datapoints))
+	}
+
+	values := series[0].Datapoints[0].Value.GetHistogram().Values
+	if len(values) != 2 {
+		t.Fatalf("expected both P50 and P99 values, got %d", len(values))
+	}
+	if values[0].Label != pb.HistogramLabel_P50 || values[0].Value != 10 {
+		t.Errorf("unexpected P50 value: %+v", values[0])
+	}
+	if values[1].Label != pb.HistogramLabel_P99 || values[1].Value != 50 {
+		t.Errorf("unexpected P99 value: %+v", values[1])
+	}
+}
+
+func TestProcessLatencyV2NoMatchingP99(t *testing.T) {
+	p50 := []*labelledSample{
+		{Labels: map[string]string{"target_deployment": "web"}, Values: []sampleVal{{Value: 10, TimestampMs: 1000}}},
+	}
+	p99 := []*labelledSample{
+		{Labels: map[string]string{"target_deployment": "api"}, Values: []sampleVal{{Value: 50, TimestampMs: 1000}}},
+	}
+
+	series := processLatencyV2(p50, p99)
+	if len(series) != 1 {
+		t.Fatalf("expected 1 series, got %d", len(series))
+	}
+
+	values := series[0].Datapoints[0].Value.GetHistogram().Values
+	if len(values) != 1 {
+		t.Fatalf("expected only a P50 value when no P99 series matches, got %d", len(values))
+	}
+}
+
+func TestLabelsKey(t *testing.T) {
+	a := labelsKey(map[string]string{"target_deployment": "web", "classification": "success"})
+	b := labelsKey(map[string]string{"classification": "success", "target_deployment": "web"})
+	if a != b {
+		t.Errorf("expected labelsKey to be order-independent, got %q and %q", a, b)
+	}
+
+	c := labelsKey(map[string]string{"target_deployment": "api", "classification": "success"})
+	if a == c {
+		t.Errorf("expected differing label sets to produce different keys, both were %q", a)
+	}
+}
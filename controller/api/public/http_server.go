@@ -17,6 +17,7 @@ import (
 	telemPb "github.com/runconduit/conduit/controller/gen/controller/telemetry"
 	pb "github.com/runconduit/conduit/controller/gen/public"
 	"github.com/runconduit/conduit/controller/util"
+	"github.com/runconduit/conduit/pkg/resourceQuery"
 	log "github.com/sirupsen/logrus"
 	"google.golang.org/grpc/metadata"
 )
@@ -122,37 +123,32 @@ func (h *handler) handleStatV2(w http.ResponseWriter, req *http.Request) {
 
 func (h *handler) handleNewStat(w http.ResponseWriter, req *http.Request) {
 	ns := req.URL.Query().Get("namespace")
+	resourceName := req.URL.Query().Get("resource")
+	if resourceName == "" {
+		writeErrorToHttpResponse(w, errors.New("specify a resource type"))
+		return
+	}
 
 	var err error
-	var result []byte
-
-	switch resource := req.URL.Query().Get("resource"); resource {
-	case "all":
-		rsp, err := h.getAllResourceMetrics(req.Context(), ns)
-		if err != nil {
-			writeErrorToHttpResponse(w, err)
-			return
-		}
-		result, err = json.Marshal(rsp)
-	case "deployments":
-		rsp, err := h.getDeploymentMetrics(req.Context(), ns)
-		if err != nil {
-			writeErrorToHttpResponse(w, err)
-			return
+	var resources interface{}
+	var warnings []string
+
+	if resourceName == resourceQuery.AllResources {
+		resources, warnings, err = h.getAllResourceMetrics(req.Context(), ns)
+	} else {
+		var resourceList []resourceQuery.Resource
+		resourceList, err = resourceQuery.ByName(resourceName)
+		if err == nil {
+			resources, warnings, err = h.getResourceMetrics(req.Context(), ns, resourceList[0])
 		}
-		result, err = json.Marshal(rsp)
-	case "pods":
-		rsp, err := h.getPodMetrics(req.Context(), ns)
-		if err != nil {
-			writeErrorToHttpResponse(w, err)
-			return
-		}
-		result, err = json.Marshal(rsp)
-	default:
-		writeErrorToHttpResponse(w, errors.New("specify a resource type"))
+	}
+
+	if err != nil {
+		writeErrorToHttpResponse(w, err)
 		return
 	}
 
+	result, err := json.Marshal(newStatResponse{Resources: resources, Warnings: warnings})
 	if err != nil {
 		writeErrorToHttpResponse(w, err)
 		return
@@ -162,6 +158,14 @@ func (h *handler) handleNewStat(w http.ResponseWriter, req *http.Request) {
 	w.Write(result)
 }
 
+// newStatResponse is the JSON envelope for handleNewStat, carrying any
+// Prometheus warnings (e.g. querier limits truncating results) alongside
+// the resource metrics.
+type newStatResponse struct {
+	Resources interface{} `json:"resources"`
+	Warnings  []string    `json:"warnings,omitempty"`
+}
+
 func (h *handler) handleVersion(w http.ResponseWriter, req *http.Request) {
 	var protoRequest pb.Empty
 	err := httpRequestToProto(req, &protoRequest)
@@ -5,17 +5,26 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/duration"
+	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/common/model"
+	"github.com/runconduit/conduit/pkg/resourceQuery"
 	log "github.com/sirupsen/logrus"
 )
 
 const (
 	reqTotalQuery = "sum(irate(request_total{namepace=\"%s\"}[10m])) by (%s)"
+
+	// minQueryRangeStep is the smallest step we'll ask Prometheus for,
+	// regardless of how small the requested window is.
+	minQueryRangeStep = 15 * time.Second
 )
 
 type promResult struct {
-	res []*labelledSample
-	err error
+	res      []*labelledSample
+	warnings []string
+	err      error
 }
 type sampleVal struct {
 	Value       float64 `json:"value"`
@@ -23,63 +32,124 @@ type sampleVal struct {
 }
 type labelledSample struct {
 	Labels map[string]string `json:"labels"`
-	Value  *sampleVal        `json:"values"`
-}
-
-func (h *handler) podRequestRate(ctx context.Context, namespace string) promResult {
-	query := fmt.Sprintf(reqTotalQuery, namespace, "k8s_pod_template_hash") // k8s_pod_template_hash is grouped pods; use pod name when available
-	return h.queryProm(ctx, query)
+	Values []sampleVal       `json:"values"`
 }
 
-func (h *handler) deployRequestRate(ctx context.Context, namespace string) promResult {
-	query := fmt.Sprintf(reqTotalQuery, namespace, "k8s_deployment")
-	return h.queryProm(ctx, query)
+// requestRateFor issues the request-rate query for a single resource kind.
+func (h *handler) requestRateFor(ctx context.Context, namespace string, resource resourceQuery.Resource, window *duration.Duration) promResult {
+	query := fmt.Sprintf(reqTotalQuery, namespace, resource.PromLabel)
+	return h.queryProm(ctx, query, window)
 }
 
-func (h *handler) queryProm(ctx context.Context, query string) promResult {
+func (h *handler) queryProm(ctx context.Context, query string, window *duration.Duration) promResult {
 	result := promResult{}
-	queryRsp, err := h.QueryProm(ctx, query)
+	queryRsp, warnings, err := h.QueryProm(ctx, query, window)
 	if err != nil {
 		result.err = err
 		return result
 	}
 	result.res = queryRsp
+	result.warnings = warnings
 	return result
 }
 
-func (h *handler) QueryProm(ctx context.Context, query string) ([]*labelledSample, error) {
-	log.Debugf("Query request: %+v", query)
+// QueryProm issues an instantaneous query when window is nil, or a ranged
+// query covering [now-window, now] when one is provided. Any warnings
+// Prometheus returns alongside a successful response (e.g. about querier
+// limits truncating results) are passed through rather than discarded.
+func (h *handler) QueryProm(ctx context.Context, query string, window *duration.Duration) ([]*labelledSample, []string, error) {
+	log.Debugf("Query request: %+v, window: %+v", query, window)
+
+	if window == nil {
+		return h.queryPromInstant(ctx, query)
+	}
+	return h.queryPromRange(ctx, query, window)
+}
+
+func (h *handler) queryPromInstant(ctx context.Context, query string) ([]*labelledSample, []string, error) {
 	end := time.Now()
-	samples := make([]*labelledSample, 0)
 
-	// single data point (aka summary) query
-	res, err := h.prometheusAPI.Query(ctx, query, end)
+	res, warnings, err := h.prometheusAPI.Query(ctx, query, end)
 	if err != nil {
 		log.Errorf("Query(%+v, %+v) failed with: %+v", query, end, err)
-		return nil, err
+		return nil, nil, err
 	}
-	log.Debugf("Query response: %+v", res)
+	log.Debugf("Query response: %+v, warnings: %+v", res, warnings)
 
 	if res.Type() != model.ValVector {
 		err = fmt.Errorf("Unexpected query result type (expected Vector): %s", res.Type())
 		log.Error(err)
-		return nil, err
+		return nil, nil, err
 	}
 
+	samples := make([]*labelledSample, 0)
 	for _, s := range res.(model.Vector) {
 		samples = append(samples, convertSample(s))
 	}
 
-	return samples, nil
+	return samples, []string(warnings), nil
+}
+
+func (h *handler) queryPromRange(ctx context.Context, query string, window *duration.Duration) ([]*labelledSample, []string, error) {
+	w, err := ptypes.Duration(window)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	end := time.Now()
+	r := promv1.Range{
+		Start: end.Add(-w),
+		End:   end,
+		Step:  queryRangeStep(w),
+	}
+
+	res, warnings, err := h.prometheusAPI.QueryRange(ctx, query, r)
+	if err != nil {
+		log.Errorf("QueryRange(%+v, %+v) failed with: %+v", query, r, err)
+		return nil, nil, err
+	}
+	log.Debugf("QueryRange response: %+v, warnings: %+v", res, warnings)
+
+	if res.Type() != model.ValMatrix {
+		err = fmt.Errorf("Unexpected query result type (expected Matrix): %s", res.Type())
+		log.Error(err)
+		return nil, nil, err
+	}
+
+	samples := make([]*labelledSample, 0)
+	for _, s := range res.(model.Matrix) {
+		samples = append(samples, convertSampleStream(s))
+	}
+
+	return samples, []string(warnings), nil
+}
+
+// queryRangeStep picks a step size proportional to the query window, the
+// same heuristic Prometheus's own e2e tests use: roughly one sample per 60th
+// of the window, floored at minQueryRangeStep so small windows don't result
+// in an unreasonable number of samples.
+func queryRangeStep(window time.Duration) time.Duration {
+	step := window / 60
+	if step < minQueryRangeStep {
+		return minQueryRangeStep
+	}
+	return step
 }
 
 func convertSample(sample *model.Sample) *labelledSample {
-	value := sampleVal{
-		Value:       float64(sample.Value),
-		TimestampMs: int64(sample.Timestamp),
+	return &labelledSample{
+		Values: []sampleVal{{Value: float64(sample.Value), TimestampMs: int64(sample.Timestamp)}},
+		Labels: metricToMap(sample.Metric),
+	}
+}
+
+func convertSampleStream(stream *model.SampleStream) *labelledSample {
+	values := make([]sampleVal, 0, len(stream.Values))
+	for _, pair := range stream.Values {
+		values = append(values, sampleVal{Value: float64(pair.Value), TimestampMs: int64(pair.Timestamp)})
 	}
 
-	return &labelledSample{Value: &value, Labels: metricToMap(sample.Metric)}
+	return &labelledSample{Values: values, Labels: metricToMap(stream.Metric)}
 }
 
 func metricToMap(metric model.Metric) map[string]string {
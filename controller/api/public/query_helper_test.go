@@ -0,0 +1,110 @@
+package public
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+)
+
+// mockPromAPI embeds promv1.API so tests only need to implement the methods
+// they exercise; any call to an un-implemented method will panic on the nil
+// embedded interface.
+type mockPromAPI struct {
+	promv1.API
+	queryResult      model.Value
+	queryRangeResult model.Value
+	warnings         promv1.Warnings
+	err              error
+}
+
+func (m *mockPromAPI) Query(ctx context.Context, query string, ts time.Time) (model.Value, promv1.Warnings, error) {
+	return m.queryResult, m.warnings, m.err
+}
+
+func (m *mockPromAPI) QueryRange(ctx context.Context, query string, r promv1.Range) (model.Value, promv1.Warnings, error) {
+	return m.queryRangeResult, m.warnings, m.err
+}
+
+func TestQueryProm(t *testing.T) {
+	t.Run("Issues an instant query when no window is given", func(t *testing.T) {
+		mock := &mockPromAPI{
+			queryResult: model.Vector{
+				&model.Sample{
+					Metric:    model.Metric{"k8s_deployment": "web"},
+					Value:     123.4,
+					Timestamp: 456,
+				},
+			},
+		}
+		mock.warnings = promv1.Warnings{"query logged too many samples"}
+		h := &handler{prometheusAPI: mock}
+
+		samples, warnings, err := h.QueryProm(context.Background(), "some_query", nil)
+		if err != nil {
+			t.Fatalf("QueryProm returned unexpected error: %v", err)
+		}
+		if len(samples) != 1 {
+			t.Fatalf("expected 1 sample, got %d", len(samples))
+		}
+		if len(samples[0].Values) != 1 || samples[0].Values[0].Value != 123.4 {
+			t.Errorf("unexpected values: %+v", samples[0].Values)
+		}
+		if samples[0].Labels["k8s_deployment"] != "web" {
+			t.Errorf("unexpected labels: %+v", samples[0].Labels)
+		}
+		if len(warnings) != 1 || warnings[0] != "query logged too many samples" {
+			t.Errorf("unexpected warnings: %+v", warnings)
+		}
+	})
+
+	t.Run("Issues a range query and returns multiple datapoints when a window is given", func(t *testing.T) {
+		mock := &mockPromAPI{
+			queryRangeResult: model.Matrix{
+				&model.SampleStream{
+					Metric: model.Metric{"k8s_deployment": "web"},
+					Values: []model.SamplePair{
+						{Value: 1, Timestamp: 100},
+						{Value: 2, Timestamp: 200},
+					},
+				},
+			},
+		}
+		h := &handler{prometheusAPI: mock}
+
+		window := ptypes.DurationProto(1 * time.Minute)
+		samples, _, err := h.QueryProm(context.Background(), "some_query", window)
+		if err != nil {
+			t.Fatalf("QueryProm returned unexpected error: %v", err)
+		}
+		if len(samples) != 1 {
+			t.Fatalf("expected 1 sample, got %d", len(samples))
+		}
+		if len(samples[0].Values) != 2 {
+			t.Fatalf("expected 2 datapoints, got %d", len(samples[0].Values))
+		}
+		if samples[0].Values[0].Value != 1 || samples[0].Values[1].Value != 2 {
+			t.Errorf("unexpected values: %+v", samples[0].Values)
+		}
+	})
+}
+
+func TestQueryRangeStep(t *testing.T) {
+	cases := []struct {
+		window   time.Duration
+		expected time.Duration
+	}{
+		{window: 1 * time.Minute, expected: minQueryRangeStep},
+		{window: 20 * time.Minute, expected: 20 * time.Second},
+		{window: 10 * time.Hour, expected: 10 * time.Minute},
+	}
+
+	for _, c := range cases {
+		if step := queryRangeStep(c.window); step != c.expected {
+			t.Errorf("queryRangeStep(%s) = %s, expected %s", c.window, step, c.expected)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package telemetry
+
+import (
+	"context"
+
+	telemPb "github.com/runconduit/conduit/controller/gen/controller/telemetry"
+	pkgTelemetry "github.com/runconduit/conduit/pkg/telemetry"
+)
+
+// server implements telemPb.TelemetryServer, the proxy-facing endpoint that
+// feeds pkg/telemetry's counters/histogram so the public API's request-rate,
+// success-rate, and latency queries have data to aggregate.
+type server struct{}
+
+// NewServer returns a telemPb.TelemetryServer ready to be registered against
+// a grpc.Server.
+func NewServer() telemPb.TelemetryServer {
+	return &server{}
+}
+
+// Report records the proxy's observed request/response via pkg/telemetry.Report.
+func (s *server) Report(ctx context.Context, req *telemPb.ReportRequest) (*telemPb.ReportResponse, error) {
+	pkgTelemetry.Report(req.SourceDeployment, req.TargetDeployment, req.HttpStatusCode, float64(req.LatencyMs))
+	return &telemPb.ReportResponse{}, nil
+}
@@ -0,0 +1,173 @@
+// Package resourceQuery defines the Kubernetes resource kinds the public API
+// reports metrics for, so that PromQL construction and the Kubernetes
+// listing used to seed zero-valued rows aren't duplicated per resource type
+// across handler and grpcServer.
+package resourceQuery
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// AllResources is the value of `--resource`/`MetricRequestV2.Resource` that
+// requests metrics aggregated across every registered resource kind.
+const AllResources = "all"
+
+// Resource describes one Kubernetes resource kind the public API reports
+// metrics for.
+type Resource struct {
+	// Name is the value passed via `--resource`/`MetricRequestV2.Resource`.
+	Name string
+	// PromLabel is the Prometheus label conduit's proxy telemetry groups
+	// requests by for this resource kind, e.g. "k8s_deployment". This only
+	// applies to request_total (REQUEST_RATE) - responses_total and
+	// response_latency_ms_bucket (SUCCESS_RATE/LATENCY/RESPONSE_CLASS) carry
+	// no per-resource-kind label at all, so those metrics always group by
+	// target_deployment regardless of PromLabel; see helper3.go.
+	PromLabel string
+	// K8sListFn lists the names of every resource of this kind in
+	// namespace, keyed the same way PromLabel values are, so the caller can
+	// seed zero-valued rows for resources with no observed traffic.
+	K8sListFn func(k8sClient *kubernetes.Clientset, namespace string) ([]string, error)
+}
+
+// registry is every resource kind the public API knows how to report
+// metrics for. The PromLabel values for replicasets/daemonsets/jobs/
+// replicationcontrollers/namespaces extend the k8s_deployment/
+// k8s_pod_template_hash convention the deployments/pods entries already
+// used; like that existing convention, they aren't independently verified
+// against an actual telemetry emitter in this tree.
+var registry = []Resource{
+	{Name: "deployments", PromLabel: "k8s_deployment", K8sListFn: listDeployments},
+	{Name: "pods", PromLabel: "k8s_pod_template_hash", K8sListFn: listPods},
+	{Name: "replicasets", PromLabel: "k8s_replica_set", K8sListFn: listReplicaSets},
+	{Name: "daemonsets", PromLabel: "k8s_daemon_set", K8sListFn: listDaemonSets},
+	{Name: "jobs", PromLabel: "k8s_job", K8sListFn: listJobs},
+	{Name: "replicationcontrollers", PromLabel: "k8s_replication_controller", K8sListFn: listReplicationControllers},
+	{Name: "namespaces", PromLabel: "namespace", K8sListFn: listNamespaces},
+}
+
+// ByName returns the Resources matching a MetricRequestV2.Resource value:
+// every registered kind for AllResources, or a single-element slice for the
+// matching kind otherwise. It errors on an unrecognized resource name.
+func ByName(name string) ([]Resource, error) {
+	if name == AllResources {
+		return registry, nil
+	}
+
+	for _, r := range registry {
+		if r.Name == name {
+			return []Resource{r}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported resource type: %q", name)
+}
+
+func listDeployments(k8sClient *kubernetes.Clientset, namespace string) ([]string, error) {
+	list, err := k8sClient.AppsV1beta1().Deployments(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(list.Items))
+	for _, item := range list.Items {
+		names = append(names, item.Name)
+	}
+	return names, nil
+}
+
+// listPods lists distinct pod-template-hash values rather than pod names,
+// since that's what PromLabel groups pod traffic by.
+func listPods(k8sClient *kubernetes.Clientset, namespace string) ([]string, error) {
+	list, err := k8sClient.CoreV1().Pods(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(list.Items))
+	seen := make(map[string]bool, len(list.Items))
+	for _, item := range list.Items {
+		hash := item.Labels["pod-template-hash"]
+		if seen[hash] {
+			continue
+		}
+		seen[hash] = true
+		names = append(names, hash)
+	}
+	return names, nil
+}
+
+func listReplicaSets(k8sClient *kubernetes.Clientset, namespace string) ([]string, error) {
+	list, err := k8sClient.ExtensionsV1beta1().ReplicaSets(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(list.Items))
+	for _, item := range list.Items {
+		names = append(names, item.Name)
+	}
+	return names, nil
+}
+
+func listDaemonSets(k8sClient *kubernetes.Clientset, namespace string) ([]string, error) {
+	list, err := k8sClient.ExtensionsV1beta1().DaemonSets(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(list.Items))
+	for _, item := range list.Items {
+		names = append(names, item.Name)
+	}
+	return names, nil
+}
+
+func listJobs(k8sClient *kubernetes.Clientset, namespace string) ([]string, error) {
+	list, err := k8sClient.BatchV1().Jobs(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(list.Items))
+	for _, item := range list.Items {
+		names = append(names, item.Name)
+	}
+	return names, nil
+}
+
+func listReplicationControllers(k8sClient *kubernetes.Clientset, namespace string) ([]string, error) {
+	list, err := k8sClient.CoreV1().ReplicationControllers(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(list.Items))
+	for _, item := range list.Items {
+		names = append(names, item.Name)
+	}
+	return names, nil
+}
+
+// listNamespaces returns namespace itself when one was requested, since the
+// Prometheus "namespace" label is already scoped to it; otherwise it lists
+// every namespace in the cluster.
+func listNamespaces(k8sClient *kubernetes.Clientset, namespace string) ([]string, error) {
+	if namespace != "" {
+		return []string{namespace}, nil
+	}
+
+	list, err := k8sClient.CoreV1().Namespaces().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(list.Items))
+	for _, item := range list.Items {
+		names = append(names, item.Name)
+	}
+	return names, nil
+}
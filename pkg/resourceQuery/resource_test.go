@@ -0,0 +1,62 @@
+package resourceQuery
+
+import "testing"
+
+func TestByName(t *testing.T) {
+	t.Run("all returns every registered resource", func(t *testing.T) {
+		resources, err := ByName(AllResources)
+		if err != nil {
+			t.Fatalf("ByName(%q) returned unexpected error: %v", AllResources, err)
+		}
+		if len(resources) != len(registry) {
+			t.Errorf("expected %d resources, got %d", len(registry), len(resources))
+		}
+	})
+
+	t.Run("a known name returns just that resource", func(t *testing.T) {
+		resources, err := ByName("deployments")
+		if err != nil {
+			t.Fatalf("ByName(\"deployments\") returned unexpected error: %v", err)
+		}
+		if len(resources) != 1 || resources[0].Name != "deployments" {
+			t.Errorf("expected a single \"deployments\" resource, got: %+v", resources)
+		}
+		if resources[0].PromLabel != "k8s_deployment" {
+			t.Errorf("expected PromLabel \"k8s_deployment\", got %q", resources[0].PromLabel)
+		}
+	})
+
+	t.Run("an unknown name errors", func(t *testing.T) {
+		if _, err := ByName("widgets"); err == nil {
+			t.Error("expected an error for an unrecognized resource type, got nil")
+		}
+	})
+
+	t.Run("every registered kind resolves to itself with its PromLabel", func(t *testing.T) {
+		cases := []struct {
+			name      string
+			promLabel string
+		}{
+			{name: "deployments", promLabel: "k8s_deployment"},
+			{name: "pods", promLabel: "k8s_pod_template_hash"},
+			{name: "replicasets", promLabel: "k8s_replica_set"},
+			{name: "daemonsets", promLabel: "k8s_daemon_set"},
+			{name: "jobs", promLabel: "k8s_job"},
+			{name: "replicationcontrollers", promLabel: "k8s_replication_controller"},
+			{name: "namespaces", promLabel: "namespace"},
+		}
+
+		for _, c := range cases {
+			resources, err := ByName(c.name)
+			if err != nil {
+				t.Fatalf("ByName(%q) returned unexpected error: %v", c.name, err)
+			}
+			if len(resources) != 1 || resources[0].Name != c.name {
+				t.Errorf("expected a single %q resource, got: %+v", c.name, resources)
+			}
+			if resources[0].PromLabel != c.promLabel {
+				t.Errorf("expected PromLabel %q for %q, got %q", c.promLabel, c.name, resources[0].PromLabel)
+			}
+		}
+	})
+}
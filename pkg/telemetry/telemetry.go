@@ -2,12 +2,19 @@ package telemetry
 
 import (
 	"fmt"
+	"strconv"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 const (
 	reportsMetric = "reports_total"
+
+	// successStatusCodeCeiling is the first HTTP status code classified as
+	// a failure; anything below it is a success. This matches the
+	// `classification="success"` filter the public API's success-rate
+	// queries already use.
+	successStatusCodeCeiling = 500
 )
 
 var (
@@ -56,6 +63,39 @@ var (
 	)
 )
 
+func init() {
+	prometheus.MustRegister(requestsTotal, responsesTotal, responseLatency, reportsTotal)
+}
+
+// Report records one observed request/response against requestsTotal,
+// responsesTotal, and responseLatency, so the public API's request-rate,
+// success-rate, and latency queries have data to aggregate. statusCode is
+// classified into "success"/"failure" via Classify.
+func Report(source, target string, statusCode int32, latencyMs float64) {
+	requestLabelValues := prometheus.Labels{
+		"source_deployment": source,
+		"target_deployment": target,
+	}
+	requestsTotal.With(requestLabelValues).Inc()
+	responseLatency.With(requestLabelValues).Observe(latencyMs)
+
+	responsesTotal.With(prometheus.Labels{
+		"source_deployment": source,
+		"target_deployment": target,
+		"http_status_code":  strconv.Itoa(int(statusCode)),
+		"classification":    Classify(statusCode),
+	}).Inc()
+}
+
+// Classify buckets an HTTP status code into the "success"/"failure"
+// classification responsesTotal is labeled with.
+func Classify(statusCode int32) string {
+	if statusCode < successStatusCodeCeiling {
+		return "success"
+	}
+	return "failure"
+}
+
 func GeneratePromLabels() []string {
 	kubeResourceTypes := []string{
 		"job",
@@ -0,0 +1,47 @@
+package telemetry
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		statusCode int32
+		expected   string
+	}{
+		{statusCode: 200, expected: "success"},
+		{statusCode: 404, expected: "success"},
+		{statusCode: 499, expected: "success"},
+		{statusCode: 500, expected: "failure"},
+		{statusCode: 503, expected: "failure"},
+	}
+
+	for _, c := range cases {
+		if got := Classify(c.statusCode); got != c.expected {
+			t.Errorf("Classify(%d) = %q, expected %q", c.statusCode, got, c.expected)
+		}
+	}
+}
+
+func TestReport(t *testing.T) {
+	Report("web", "api", 200, 12.5)
+	Report("web", "api", 500, 42)
+
+	if count := testutil.ToFloat64(requestsTotal.With(map[string]string{
+		"source_deployment": "web",
+		"target_deployment": "api",
+	})); count != 2 {
+		t.Errorf("expected requestsTotal to be 2, got %v", count)
+	}
+
+	if count := testutil.ToFloat64(responsesTotal.With(map[string]string{
+		"source_deployment": "web",
+		"target_deployment": "api",
+		"http_status_code":  "500",
+		"classification":    "failure",
+	})); count != 1 {
+		t.Errorf("expected a single failure response to be recorded, got %v", count)
+	}
+}